@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/urfave/cli"
+
+	"github.com/dangermike/goredisperf/metrics"
+)
+
+// runCycles runs a single (key-count, concurrency) measurement and returns
+// one set of per-op-type histograms per worker. It dispatches closed-loop
+// (the original issue-then-wait behaviour) unless --rate is set, in which
+// case an open-loop producer schedules jobs at a fixed rate so a backed-up
+// worker's queueing delay shows up as latency instead of vanishing. rec
+// receives live in-flight/latency observations for --metrics-addr.
+func runCycles(ctx *cli.Context, client redis.UniversalClient, fetcher Fetcher, workload *Workload, sizeD sizeDist, groups [][]string, cnt, conc int, rec metrics.Recorder) []*workerHistograms {
+	cycles := ctx.GlobalInt("cycles")
+	if rate := ctx.Float64("rate"); rate > 0 {
+		return runOpenLoopCycles(client, fetcher, workload, sizeD, groups, cnt, conc, cycles, rate, ctx.String("dist"), rec)
+	}
+	return runClosedLoopCycles(client, fetcher, workload, sizeD, groups, cnt, conc, cycles, rec)
+}
+
+// runClosedLoopCycles is the original worker shape: each of conc workers is
+// pinned to one shard group and issues its next request only once the
+// previous one returns. Each iteration draws an op type from workload;
+// reads fetch cnt keys from the worker's group, writes and deletes target
+// a single random key from it.
+func runClosedLoopCycles(client redis.UniversalClient, fetcher Fetcher, workload *Workload, sizeD sizeDist, groups [][]string, cnt, conc, cycles int, rec metrics.Recorder) []*workerHistograms {
+	var wg sync.WaitGroup
+	workerHists := make([]*workerHistograms, conc)
+	indices := make(chan int) // indices into the cycle count
+	for t := 0; t < conc; t++ {
+		wg.Add(1)
+		gx := t % len(groups)
+		hists := newWorkerHistograms()
+		workerHists[t] = hists
+		go func(gx int, hists *workerHistograms) {
+			client.Get("fake") // warm up client
+			defer wg.Done()
+			mykeys := make([]string, len(groups[gx])) // copy for safety
+			copy(mykeys, groups[gx])
+			n := cnt
+			if n > len(mykeys) {
+				n = len(mykeys)
+			}
+			for range indices {
+				shuffleKeys(mykeys)
+				op := workload.pick()
+				rec.IncInFlight(op.String())
+				start := time.Now()
+				if ferr := runOp(client, fetcher, op, mykeys[:n], mykeys[0], sizeD); ferr != nil {
+					panic(ferr)
+				}
+				dur := time.Since(start)
+				rec.DecInFlight(op.String())
+				rec.Observe(op.String(), dur)
+				hists.recordOp(op, dur.Nanoseconds())
+			}
+		}(gx, hists)
+	}
+	for c := 0; c < cycles; c++ {
+		indices <- c
+	}
+	close(indices)
+	wg.Wait()
+	return workerHists
+}
+
+// runOpenLoopCycles drives the same measurement through an open-loop
+// producer: jobs are scheduled at a fixed rate regardless of worker
+// progress, and each worker's latency is measured from the job's intended
+// dispatch time rather than from when it was dequeued. Shard pinning is
+// per-job rather than per-worker here, since the producer draws a random
+// shard group for each job; callers should skip per-shard reporting for
+// this path.
+func runOpenLoopCycles(client redis.UniversalClient, fetcher Fetcher, workload *Workload, sizeD sizeDist, groups [][]string, cnt, conc, cycles int, rate float64, dist string, rec metrics.Recorder) []*workerHistograms {
+	workChan := make(chan job, conc*4)
+	nextJob := func() (opType, []string, string) {
+		gx := rand.Intn(len(groups))
+		mykeys := make([]string, len(groups[gx]))
+		copy(mykeys, groups[gx])
+		shuffleKeys(mykeys)
+		n := cnt
+		if n > len(mykeys) {
+			n = len(mykeys)
+		}
+		return workload.pick(), mykeys[:n], mykeys[0]
+	}
+
+	var wg sync.WaitGroup
+	workerHists := make([]*workerHistograms, conc)
+	for t := 0; t < conc; t++ {
+		wg.Add(1)
+		hists := newWorkerHistograms()
+		workerHists[t] = hists
+		go func(hists *workerHistograms) {
+			client.Get("fake") // warm up client
+			defer wg.Done()
+			for j := range workChan {
+				rec.IncInFlight(j.op.String())
+				if ferr := runOp(client, fetcher, j.op, j.keys, j.writeKey, sizeD); ferr != nil {
+					panic(ferr)
+				}
+				rec.DecInFlight(j.op.String())
+				dur := time.Since(j.scheduledAt)
+				rec.Observe(j.op.String(), dur)
+				hists.recordOp(j.op, dur.Nanoseconds())
+			}
+		}(hists)
+	}
+	openLoopDispatch(workChan, cycles, rate, dist, nextJob)
+	wg.Wait()
+	return workerHists
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// job carries one operation's type and target keys along with the time it
+// was meant to be dispatched, so a worker that picks it up late can report
+// the resulting queueing delay as part of its latency rather than that
+// delay silently disappearing.
+type job struct {
+	op          opType
+	keys        []string
+	writeKey    string
+	scheduledAt time.Time
+}
+
+// openLoopDispatch issues `cycles` jobs at the given rate (ops/sec) from a
+// single producer goroutine into workChan, independent of how quickly
+// workers drain it. This is what makes the load open-loop: a closed loop
+// (issue a request, wait for it, issue the next) hides tail latency under
+// load because a worker that falls behind simply issues its next request
+// later, so the queueing delay it caused never shows up as latency
+// (coordinated omission). Here the producer keeps issuing on schedule
+// regardless of worker progress, and callers measure latency from
+// scheduledAt rather than from whenever the job was dequeued, so a
+// backed-up worker's queueing delay is counted the same as processing time.
+//
+// dist selects the inter-arrival distribution: "poisson" draws exponential
+// gaps for a memoryless arrival process matching real-world request
+// traffic, "uniform" spaces jobs evenly at the target rate.
+func openLoopDispatch(workChan chan<- job, cycles int, rate float64, dist string, nextJob func() (opType, []string, string)) {
+	defer close(workChan)
+	interval := time.Duration(float64(time.Second) / rate)
+	next := time.Now()
+	for i := 0; i < cycles; i++ {
+		sleepUntil(next)
+		op, keys, writeKey := nextJob()
+		workChan <- job{op: op, keys: keys, writeKey: writeKey, scheduledAt: next}
+		if dist == "uniform" {
+			next = next.Add(interval)
+		} else {
+			next = next.Add(time.Duration(rand.ExpFloat64() * float64(interval)))
+		}
+	}
+}
+
+func sleepUntil(t time.Time) {
+	if d := time.Until(t); d > 0 {
+		time.Sleep(d)
+	}
+}
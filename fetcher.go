@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-redis/redis"
+	"github.com/urfave/cli"
+)
+
+// Fetcher retrieves the values for a set of keys using a particular
+// strategy, so the worker loop can be pointed at MGET, a pipeline of GETs,
+// a transactional pipeline, or a Lua script without changing its shape.
+type Fetcher interface {
+	// Name identifies the strategy for column headers and flag values.
+	Name() string
+	// Fetch retrieves keys and returns the first error encountered, if any.
+	Fetch(client redis.Cmdable, keys []string) error
+}
+
+// fetcherNames lists the valid --strategy values, in the order they should
+// be reported when all strategies are benchmarked together.
+var fetcherNames = []string{"mget", "pipeline", "txpipeline", "lua"}
+
+// newFetcher builds the Fetcher for the given --strategy value. script is
+// the SHA1 of the Lua script loaded by loadFetchScript, required by the
+// "lua" strategy.
+func newFetcher(name string, script string) (Fetcher, error) {
+	switch name {
+	case "mget":
+		return mgetFetcher{}, nil
+	case "pipeline":
+		return pipelineFetcher{}, nil
+	case "txpipeline":
+		return txPipelineFetcher{}, nil
+	case "lua":
+		if script == "" {
+			return nil, fmt.Errorf("lua strategy requires a loaded script")
+		}
+		return luaFetcher{sha: script}, nil
+	default:
+		return nil, fmt.Errorf("unknown strategy %q (want one of %v)", name, fetcherNames)
+	}
+}
+
+// buildFetcher resolves the --strategy flag into a Fetcher, loading the
+// Lua script onto the server first if the strategy needs it.
+func buildFetcher(ctx *cli.Context, client redis.Cmdable) (Fetcher, error) {
+	name := ctx.GlobalString("strategy")
+	script := ""
+	if name == "lua" {
+		sha, err := loadFetchScript(client)
+		if err != nil {
+			return nil, err
+		}
+		script = sha
+	}
+	return newFetcher(name, script)
+}
+
+type mgetFetcher struct{}
+
+func (mgetFetcher) Name() string { return "mget" }
+
+func (mgetFetcher) Fetch(client redis.Cmdable, keys []string) error {
+	_, err := client.MGet(keys...).Result()
+	return err
+}
+
+// pipelineFetcher issues one GET per key over a single pipeline, mirroring
+// what an MGET does server-side but letting the client batch the writes.
+type pipelineFetcher struct{}
+
+func (pipelineFetcher) Name() string { return "pipeline" }
+
+func (pipelineFetcher) Fetch(client redis.Cmdable, keys []string) error {
+	pipe := client.Pipeline()
+	defer pipe.Close() //nolint
+	for _, k := range keys {
+		pipe.Get(k)
+	}
+	_, err := pipe.Exec()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	return nil
+}
+
+// txPipelineFetcher wraps the same GETs in MULTI/EXEC so the batch is
+// applied atomically, at the cost of the extra round-trip MULTI/EXEC add.
+type txPipelineFetcher struct{}
+
+func (txPipelineFetcher) Name() string { return "txpipeline" }
+
+func (txPipelineFetcher) Fetch(client redis.Cmdable, keys []string) error {
+	pipe := client.TxPipeline()
+	defer pipe.Close() //nolint
+	for _, k := range keys {
+		pipe.Get(k)
+	}
+	_, err := pipe.Exec()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	return nil
+}
+
+// luaScript returns N values in one round trip via a single EVALSHA,
+// avoiding both the MGET cross-slot restriction and the pipeline's
+// multiple command frames.
+const luaScript = `
+local vals = {}
+for i, k in ipairs(KEYS) do
+	vals[i] = redis.call('GET', k)
+end
+return vals
+`
+
+// loadFetchScript loads luaScript onto the server and returns its SHA1, for
+// use with the "lua" strategy.
+func loadFetchScript(client redis.Cmdable) (string, error) {
+	return client.ScriptLoad(luaScript).Result()
+}
+
+type luaFetcher struct {
+	sha string
+}
+
+func (luaFetcher) Name() string { return "lua" }
+
+func (f luaFetcher) Fetch(client redis.Cmdable, keys []string) error {
+	_, err := client.EvalSha(f.sha, keys).Result()
+	return err
+}
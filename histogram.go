@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Latency histograms track everything from 1 microsecond to 5 minutes at
+// 3 significant digits, which keeps per-bucket error under 0.1% across the
+// whole range while using a fixed, small amount of memory regardless of how
+// many cycles are recorded -- unlike the old sort-and-take-median approach,
+// which needed the full sample held in memory and sorted on every report.
+// The upper bound has headroom well past a typical Sentinel failover pause
+// so that tail spike shows up in the percentiles instead of being silently
+// dropped.
+const (
+	histMinValue = int64(time.Microsecond)
+	histMaxValue = int64(5 * time.Minute)
+	histSigFigs  = 3
+)
+
+func newLatencyHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(histMinValue, histMaxValue, histSigFigs)
+}
+
+// mergeHistograms combines hists into a single new histogram, leaving the
+// inputs untouched.
+func mergeHistograms(hists ...*hdrhistogram.Histogram) *hdrhistogram.Histogram {
+	combined := newLatencyHistogram()
+	for _, h := range hists {
+		combined.Merge(h)
+	}
+	return combined
+}
+
+// workerHistograms holds one worker's latency histogram per op type, so a
+// mixed read/write/del workload can report each op type separately instead
+// of blending their very different latencies into one series. dropped
+// counts samples that fell outside [histMinValue, histMaxValue] and so
+// couldn't be recorded, rather than letting them vanish unreported.
+type workerHistograms struct {
+	read, write, del *hdrhistogram.Histogram
+	dropped          int64
+}
+
+func newWorkerHistograms() *workerHistograms {
+	return &workerHistograms{
+		read:  newLatencyHistogram(),
+		write: newLatencyHistogram(),
+		del:   newLatencyHistogram(),
+	}
+}
+
+func (w *workerHistograms) forOp(op opType) *hdrhistogram.Histogram {
+	switch op {
+	case opWrite:
+		return w.write
+	case opDel:
+		return w.del
+	default:
+		return w.read
+	}
+}
+
+// recordOp records a completed op's latency into the right per-op
+// histogram, counting it as dropped instead of discarding it silently if
+// it falls outside the histogram's tracked range.
+func (w *workerHistograms) recordOp(op opType, ns int64) {
+	if err := w.forOp(op).RecordValue(ns); err != nil {
+		w.dropped++
+	}
+}
+
+// mergeOpHistograms combines the named op's histogram from every worker
+// into a single new histogram.
+func mergeOpHistograms(workerHists []*workerHistograms, op opType) *hdrhistogram.Histogram {
+	combined := newLatencyHistogram()
+	for _, w := range workerHists {
+		combined.Merge(w.forOp(op))
+	}
+	return combined
+}
+
+// totalDropped sums the samples every worker couldn't record because they
+// fell outside [histMinValue, histMaxValue].
+func totalDropped(workerHists []*workerHistograms) int64 {
+	var total int64
+	for _, w := range workerHists {
+		total += w.dropped
+	}
+	return total
+}
+
+// latencyPercentiles holds the percentiles reported for a single
+// concurrency/key-count measurement, in milliseconds.
+type latencyPercentiles struct {
+	p50, p90, p99, p999, max float64
+}
+
+func percentilesFromHistogram(h *hdrhistogram.Histogram) latencyPercentiles {
+	toMillis := func(ns int64) float64 { return float64(ns) / 1000000.0 }
+	return latencyPercentiles{
+		p50:  toMillis(h.ValueAtQuantile(50)),
+		p90:  toMillis(h.ValueAtQuantile(90)),
+		p99:  toMillis(h.ValueAtQuantile(99)),
+		p999: toMillis(h.ValueAtQuantile(99.9)),
+		max:  toMillis(h.Max()),
+	}
+}
+
+func (p latencyPercentiles) print() {
+	fmt.Printf("\t%0.3f\t%0.3f\t%0.3f\t%0.3f\t%0.3f", p.p50, p.p90, p.p99, p.p999, p.max)
+}
+
+// writeHDRCDF writes h's cumulative distribution to path as tab-separated
+// "latency_ms\tquantile" rows, in a form gnuplot can render as a latency
+// CDF with `plot 'path' using 1:2 with lines`.
+func writeHDRCDF(path string, h *hdrhistogram.Histogram) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint
+
+	for _, b := range h.CumulativeDistribution() {
+		if _, werr := fmt.Fprintf(f, "%0.3f\t%0.4f\n", float64(b.ValueAt)/1000000.0, b.Quantile); werr != nil {
+			return werr
+		}
+	}
+	return nil
+}
+
+// hdrOutPathForOp inserts op's name ahead of path's extension, e.g.
+// "out.dat" becomes "out.write.dat", so a mixed workload's read, write,
+// and del latencies each land in their own CDF file instead of being
+// blended into one multimodal curve.
+func hdrOutPathForOp(path string, op opType) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	return fmt.Sprintf("%s.%s%s", base, op, ext)
+}
+
+// writeHDRCDFs writes one CDF file per op in overallHists, via
+// hdrOutPathForOp when there's more than one op so each gets a distinct
+// path, or path as-is for a single-op (e.g. read-only) workload.
+func writeHDRCDFs(path string, overallHists map[opType]*hdrhistogram.Histogram, activeOps []opType) error {
+	for _, op := range activeOps {
+		outPath := path
+		if len(activeOps) > 1 {
+			outPath = hdrOutPathForOp(path, op)
+		}
+		if err := writeHDRCDF(outPath, overallHists[op]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
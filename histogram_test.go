@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestPercentilesFromHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	for ms := 1; ms <= 100; ms++ {
+		if err := h.RecordValue(int64(ms) * 1000000); err != nil {
+			t.Fatalf("RecordValue(%dms): %v", ms, err)
+		}
+	}
+
+	p := percentilesFromHistogram(h)
+	if p.p50 < 49 || p.p50 > 51 {
+		t.Errorf("p50 = %v, want ~50", p.p50)
+	}
+	if p.p99 < 98 || p.p99 > 100 {
+		t.Errorf("p99 = %v, want ~99", p.p99)
+	}
+	if p.max < 99.9 || p.max > 100.1 {
+		t.Errorf("max = %v, want ~100", p.max)
+	}
+}
+
+func TestWorkerHistogramsRecordOpDropsOutOfRange(t *testing.T) {
+	w := newWorkerHistograms()
+	w.recordOp(opRead, int64(histMaxValue)*2)
+	if w.dropped != 1 {
+		t.Errorf("dropped = %d, want 1 after an out-of-range sample", w.dropped)
+	}
+	w.recordOp(opRead, int64(histMinValue))
+	if w.dropped != 1 {
+		t.Errorf("dropped = %d, want unchanged at 1 after an in-range sample", w.dropped)
+	}
+}
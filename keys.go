@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// testKeyPoolSize is how many test keys getTestKeys maintains.
+const testKeyPoolSize = 50000
+
+// getTestKeys returns the full test keyset, creating any keys that do not
+// already exist so that there are always testKeyPoolSize of them, with
+// value sizes drawn from sizeD. hashtagSlots controls how the keys are
+// named: at zero every key hashes independently (the original
+// scatter-everywhere behaviour); above zero each key carries a `{slotN}`
+// hashtag, which Redis Cluster uses to pin all of a group's keys to a
+// single shard.
+func getTestKeys(client redis.Cmdable, sizeD sizeDist, hashtagSlots int) ([]string, error) {
+	var keys []string
+
+	iter := client.Scan(0, "test_*", 5000).Iterator()
+	for iter.Next() {
+		keys = append(keys, iter.Val())
+	}
+	for len(keys) < testKeyPoolSize {
+		key := testKeyName(len(keys), hashtagSlots)
+		buf := make([]byte, sizeD.sample())
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		if _, err := client.Set(key, buf, time.Duration(0)).Result(); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// testKeyName builds the name of the n'th test key. With hashtagSlots above
+// zero the key is tagged `{slotN}` so every key sharing a slot number maps
+// to the same cluster slot.
+func testKeyName(n int, hashtagSlots int) string {
+	if hashtagSlots > 0 {
+		return fmt.Sprintf("test_{%d}_%05d", n%hashtagSlots, n)
+	}
+	return fmt.Sprintf("test_%05d", n)
+}
+
+// groupKeysByShard buckets keys produced by testKeyName by their hashtag
+// slot, so callers can draw every key of an MGET from a single group and
+// keep the request on one cluster shard. With hashtagSlots at zero there is
+// a single group holding every key, preserving the original
+// scatter-across-everything behaviour.
+func groupKeysByShard(keys []string, hashtagSlots int) [][]string {
+	if hashtagSlots <= 0 {
+		return [][]string{keys}
+	}
+	groups := make([][]string, hashtagSlots)
+	for _, k := range keys {
+		var slot int
+		if _, err := fmt.Sscanf(k, "test_{%d}_", &slot); err != nil || slot < 0 || slot >= hashtagSlots {
+			continue
+		}
+		groups[slot] = append(groups[slot], k)
+	}
+	return groups
+}
+
+func shuffleKeys(keys []string) {
+	rand.Shuffle(len(keys), func(i, j int) {
+		keys[i], keys[j] = keys[j], keys[i]
+	})
+}
+
+func clearTestKeys(client redis.Cmdable) (int64, error) {
+	total := int64(0)
+
+	kcnt := -1
+	for kcnt != 0 {
+		var keys []string
+		iter := client.Scan(0, "test_*", 5000).Iterator()
+		for iter.Next() {
+			keys = append(keys, iter.Val())
+		}
+		kcnt = len(keys)
+		if kcnt > 0 {
+			cnt, err := client.Del(keys...).Result()
+			total += cnt
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
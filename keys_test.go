@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTestKeyName(t *testing.T) {
+	if got := testKeyName(42, 0); got != "test_00042" {
+		t.Errorf("testKeyName(42, 0) = %q, want %q", got, "test_00042")
+	}
+	if got := testKeyName(42, 10); got != "test_{2}_00042" {
+		t.Errorf("testKeyName(42, 10) = %q, want %q", got, "test_{2}_00042")
+	}
+}
+
+func TestGroupKeysByShardScatter(t *testing.T) {
+	keys := []string{"test_00000", "test_00001", "test_00002"}
+	groups := groupKeysByShard(keys, 0)
+	if len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("groupKeysByShard(keys, 0) = %v, want a single group holding every key", groups)
+	}
+}
+
+func TestGroupKeysByShardTooManySlots(t *testing.T) {
+	hashtagSlots := 5
+	keys := []string{testKeyName(0, hashtagSlots), testKeyName(1, hashtagSlots)}
+	groups := groupKeysByShard(keys, hashtagSlots)
+	if len(groups) != hashtagSlots {
+		t.Fatalf("groupKeysByShard returned %d groups, want %d", len(groups), hashtagSlots)
+	}
+	if len(groups[2]) != 0 {
+		t.Fatalf("group 2 should be empty when hashtagSlots exceeds the key count, got %v", groups[2])
+	}
+}
+
+func TestGroupKeysByShardHashtagged(t *testing.T) {
+	hashtagSlots := 3
+	var keys []string
+	for n := 0; n < 9; n++ {
+		keys = append(keys, testKeyName(n, hashtagSlots))
+	}
+	groups := groupKeysByShard(keys, hashtagSlots)
+	if len(groups) != hashtagSlots {
+		t.Fatalf("groupKeysByShard returned %d groups, want %d", len(groups), hashtagSlots)
+	}
+	for gx, group := range groups {
+		if len(group) == 0 {
+			t.Errorf("group %d is empty", gx)
+		}
+		for _, k := range group {
+			var slot int
+			if _, err := fmt.Sscanf(k, "test_{%d}_", &slot); err != nil {
+				t.Errorf("key %q doesn't carry a parseable hashtag: %v", k, err)
+				continue
+			}
+			if slot != gx {
+				t.Errorf("key %q landed in group %d, want group %d", k, gx, slot)
+			}
+		}
+	}
+}
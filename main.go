@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
-	"sort"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/go-redis/redis"
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
 	"github.com/urfave/cli"
+
+	"github.com/dangermike/goredisperf/metrics"
 )
 
 func main() {
@@ -53,6 +55,48 @@ func main() {
 			Value: 2048,
 			Usage: "Size of test data values, in bytes",
 		},
+		cli.StringSliceFlag{
+			Name:  "cluster-addr",
+			Usage: "Additional seed address (host:port) for a Redis Cluster; repeat for multiple seeds. Triggers cluster mode",
+		},
+		cli.StringFlag{
+			Name:  "sentinel-master",
+			Usage: "Name of the Sentinel-monitored master to connect to. Triggers Sentinel failover mode",
+		},
+		cli.StringSliceFlag{
+			Name:  "sentinel-addr",
+			Usage: "Sentinel seed address (host:port); repeat for multiple sentinels. Defaults to host/port",
+		},
+		cli.IntFlag{
+			Name:  "cluster-hashtag-slots",
+			Usage: "Group test keys into this many {hashtag} groups so a single MGET stays on one cluster shard; 0 scatters keys across all shards",
+		},
+		cli.StringFlag{
+			Name:  "strategy",
+			Value: "mget",
+			Usage: fmt.Sprintf("Fetch strategy to benchmark: one of %v", fetcherNames),
+		},
+		cli.Float64Flag{
+			Name:  "read-ratio",
+			Value: 1.0,
+			Usage: "Relative weight of read ops in the workload mix (normalized against write-ratio and del-ratio)",
+		},
+		cli.Float64Flag{
+			Name:  "write-ratio",
+			Usage: "Relative weight of write (SET) ops in the workload mix",
+		},
+		cli.Float64Flag{
+			Name:  "del-ratio",
+			Usage: "Relative weight of delete (DEL + recreate) ops in the workload mix",
+		},
+		cli.StringFlag{
+			Name:  "size-dist",
+			Usage: "Value size distribution: fixed:N, uniform:min:max, or zipf:min:max:s; defaults to a fixed distribution at --data-size",
+		},
+		cli.StringFlag{
+			Name:  "metrics-addr",
+			Usage: "Serve Prometheus metrics on this host:port (e.g. :9121) and print a live progress line while the run is in flight; unset disables both",
+		},
 	}
 
 	app.Commands = []cli.Command{
@@ -71,6 +115,19 @@ func main() {
 					Value: 16,
 					Usage: "Maximum concurrency",
 				},
+				cli.StringFlag{
+					Name:  "hdr-out",
+					Usage: "Write each active op's latency histogram cumulative distribution to this path (op name inserted before the extension when more than one op is active), for a gnuplot CDF plot",
+				},
+				cli.Float64Flag{
+					Name:  "rate",
+					Usage: "Target ops/sec for an open-loop load generator; 0 keeps the default closed-loop (issue-then-wait) behaviour",
+				},
+				cli.StringFlag{
+					Name:  "dist",
+					Value: "poisson",
+					Usage: "Inter-arrival distribution for --rate: poisson or uniform",
+				},
 			},
 		},
 		cli.Command{
@@ -103,12 +160,36 @@ func main() {
 				},
 			},
 		},
+		cli.Command{
+			Name:   "strategies",
+			Usage:  "Compare fetch strategies (mget, pipeline, txpipeline, lua) at each key count",
+			Action: strategiesAction,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "concurrency",
+					Value: 8,
+					Usage: "Concurrency",
+				},
+			},
+		},
 	}
 	if err := app.Run(os.Args); err != nil {
 		panic(err)
 	}
 }
 
+// newRecorder builds the metrics.Recorder for a run: a Live recorder
+// exporting Prometheus metrics and printing a progress line if
+// --metrics-addr is set, otherwise a NopRecorder so the hot path pays no
+// bookkeeping cost. totalOps seeds the progress line's ETA estimate.
+func newRecorder(ctx *cli.Context, totalOps int64) metrics.Recorder {
+	addr := ctx.GlobalString("metrics-addr")
+	if addr == "" {
+		return metrics.NopRecorder{}
+	}
+	return metrics.NewLive(addr, totalOps)
+}
+
 func concurrencyAction(ctx *cli.Context) error {
 	if ctx.Int("min-conc") < 1 {
 		return errors.New("min-conc must be greater than zero")
@@ -119,20 +200,46 @@ func concurrencyAction(ctx *cli.Context) error {
 	if ctx.GlobalInt("cycles") < 1 {
 		return errors.New("cycles must be greater than 0")
 	}
+	if ctx.Float64("rate") < 0 {
+		return errors.New("rate must not be negative")
+	}
+	if dist := ctx.String("dist"); dist != "poisson" && dist != "uniform" {
+		return fmt.Errorf("dist must be one of poisson, uniform (got %q)", dist)
+	}
+	if err := validateClusterScatterStrategy(ctx); err != nil {
+		return err
+	}
 
-	client := redis.NewClient(ctxToRedisOptions(ctx))
+	client := newRedisClient(ctx)
 	defer client.Close() //nolint
 
 	if _, err := clearTestKeys(client); err != nil {
 		panic(err)
 	}
 
-	keys, err := getTestKeys(client, ctx.GlobalInt("data-size"))
+	sizeD, err := resolveSizeDist(ctx)
+	if err != nil {
+		return err
+	}
+	workload, err := resolveWorkload(ctx)
+	if err != nil {
+		return err
+	}
+
+	hashtagSlots := ctx.GlobalInt("cluster-hashtag-slots")
+	keys, err := getTestKeys(client, sizeD, hashtagSlots)
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("Holding %d keys\n", len(keys))
+	groups := groupKeysByShard(keys, hashtagSlots)
 
+	fetcher, err := buildFetcher(ctx, client)
+	if err != nil {
+		panic(err)
+	}
+
+	activeOps := workload.activeOps()
 	counts := []int{1, 1}
 	for i := 5; i <= 100; i += 5 {
 		counts = append(counts, i)
@@ -141,55 +248,83 @@ func concurrencyAction(ctx *cli.Context) error {
 	for c := ctx.Int("min-conc"); c <= ctx.Int("max-conc"); c <<= 1 {
 		concs = append(concs, c)
 	}
+
+	rec := newRecorder(ctx, int64(len(counts)*len(concs)*ctx.GlobalInt("cycles")))
+	defer rec.Finish()
+
 	fmt.Print("keys")
 	for _, c := range concs {
-		fmt.Printf("\tc=%d", c)
+		for _, op := range activeOps {
+			fmt.Printf("\tc=%d-%s-p50\tc=%d-%s-p90\tc=%d-%s-p99\tc=%d-%s-p99.9\tc=%d-%s-max", c, op, c, op, c, op, c, op, c, op)
+		}
 	}
 	fmt.Println()
+	overallHists := make(map[opType]*hdrhistogram.Histogram, len(activeOps))
+	for _, op := range activeOps {
+		overallHists[op] = newLatencyHistogram()
+	}
+	var dropped int64
 	for _, cnt := range counts {
-		res := make([]int64, ctx.GlobalInt("cycles"))
 		fmt.Print(cnt)
 		for _, conc := range concs {
-			var wg sync.WaitGroup
-			indices := make(chan int) // indices into the result slice
-			for t := 0; t < conc; t++ {
-				wg.Add(1)
-				go func() {
-					client.Get("fake") // warm up client
-					defer wg.Done()
-					mykeys := make([]string, len(keys)) // copy for safety
-					copy(mykeys, keys)
-					for ix := range indices { // ix is the index where the duration will be written
-						shuffleKeys(mykeys)
-						start := time.Now()
-						cmd := client.MGet(mykeys[:cnt]...)
-						dur := time.Since(start)
-						if _, rerr := cmd.Result(); rerr != nil {
-							panic(rerr)
-						}
-						res[ix] = dur.Nanoseconds()
-					}
-				}()
+			workerHists := runCycles(ctx, client, fetcher, workload, sizeD, groups, cnt, conc, rec)
+			dropped += totalDropped(workerHists)
+
+			for _, op := range activeOps {
+				combined := mergeOpHistograms(workerHists, op)
+				overallHists[op].Merge(combined)
+				percentilesFromHistogram(combined).print()
 			}
-			for c := 0; c < ctx.GlobalInt("cycles"); c++ {
-				indices <- c
+			if len(groups) > 1 && ctx.Float64("rate") <= 0 {
+				printShardBreakdown(conc, groups, workerHists)
 			}
-			close(indices)
-			wg.Wait()
-			sortInt64(res)
-			fmt.Printf("\t%0.3f", medianInt64(res)/1000000.0)
 		}
 		fmt.Println()
 	}
 
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d samples fell outside [%s, %s] and were dropped from the histograms\n", dropped, time.Duration(histMinValue), time.Duration(histMaxValue))
+	}
+
+	if hdrOut := ctx.String("hdr-out"); hdrOut != "" {
+		if werr := writeHDRCDFs(hdrOut, overallHists, activeOps); werr != nil {
+			return werr
+		}
+	}
+
 	cnt, err := clearTestKeys(client)
 	fmt.Printf("Deleted %d test keys\n", cnt)
 
 	return err
 }
 
+// printShardBreakdown reports the per-shard read percentiles for a single
+// concurrency level, alongside the aggregate figures printed by the
+// caller. Each worker is pinned to a single shard group for its whole run
+// (see the gx assignment above), so its histogram can be attributed to
+// that shard directly. Only the read op type is broken down, since shard
+// pinning exists to keep an MGET on one shard.
+func printShardBreakdown(conc int, groups [][]string, workerHists []*workerHistograms) {
+	byShard := make(map[int][]*hdrhistogram.Histogram)
+	for t, hists := range workerHists {
+		gx := t % len(groups)
+		byShard[gx] = append(byShard[gx], hists.read)
+	}
+	for gx := 0; gx < len(groups); gx++ {
+		hists, ok := byShard[gx]
+		if !ok {
+			continue
+		}
+		combined := mergeHistograms(hists...)
+		p := percentilesFromHistogram(combined)
+		fmt.Printf("\n  c=%d shard=%d p50=%0.3fms p99=%0.3fms max=%0.3fms n=%d", conc, gx, p.p50, p.p99, p.max, combined.TotalCount())
+	}
+}
+
 type scatterItem struct {
 	keys     int
+	shard    int
+	op       opType
 	duration time.Duration
 }
 
@@ -206,23 +341,44 @@ func scatterAction(ctx *cli.Context) error {
 	if ctx.Int("concurrency") < 1 {
 		return errors.New("concurrency must be greater than 0")
 	}
+	if err := validateClusterScatterStrategy(ctx); err != nil {
+		return err
+	}
 
-	client := redis.NewClient(ctxToRedisOptions(ctx))
+	client := newRedisClient(ctx)
 	defer client.Close() //nolint
 
 	if _, err := clearTestKeys(client); err != nil {
 		panic(err)
 	}
 
-	keys, err := getTestKeys(client, ctx.GlobalInt("data-size"))
+	sizeD, err := resolveSizeDist(ctx)
+	if err != nil {
+		return err
+	}
+	workload, err := resolveWorkload(ctx)
+	if err != nil {
+		return err
+	}
+
+	hashtagSlots := ctx.GlobalInt("cluster-hashtag-slots")
+	keys, err := getTestKeys(client, sizeD, hashtagSlots)
 	if err != nil {
 		panic(err)
 	}
+	groups := groupKeysByShard(keys, hashtagSlots)
 
-	if ctx.Bool("gnuplot") {
-		fmt.Println("$DATABLOCK << EOD")
+	fetcher, err := buildFetcher(ctx, client)
+	if err != nil {
+		panic(err)
 	}
 
+	activeOps := workload.activeOps()
+	byOp := make(map[opType][]scatterItem)
+
+	rec := newRecorder(ctx, int64(ctx.GlobalInt("cycles")))
+	defer rec.Finish()
+
 	var wgWorkers sync.WaitGroup
 	var wgWriter sync.WaitGroup
 	indices := make(chan int) // indices into the result slice
@@ -235,31 +391,49 @@ func scatterAction(ctx *cli.Context) error {
 	go func() {
 		defer wgWriter.Done()
 		for si := range outchan {
-			fmt.Printf("%d\t%0.3f\n", si.keys, float64(si.duration.Nanoseconds())/1000000.0)
+			byOp[si.op] = append(byOp[si.op], si)
+			if ctx.Bool("gnuplot") {
+				continue
+			}
+			line := fmt.Sprintf("%d\t%0.3f", si.keys, float64(si.duration.Nanoseconds())/1000000.0)
+			if len(activeOps) > 1 {
+				line += fmt.Sprintf("\top=%s", si.op)
+			}
+			if len(groups) > 1 {
+				line += fmt.Sprintf("\tshard=%d", si.shard)
+			}
+			fmt.Println(line)
 		}
 	}()
 	for t := 0; t < ctx.Int("concurrency"); t++ {
 		wgWorkers.Add(1)
-		go func() {
+		gx := t % len(groups)
+		go func(gx int) {
 			client.Get("fake") // warm up client
 			defer wgWorkers.Done()
-			mykeys := make([]string, len(keys)) // copy for safety
-			copy(mykeys, keys)
+			mykeys := make([]string, len(groups[gx])) // copy for safety
+			copy(mykeys, groups[gx])
 			for range indices {
 				keyCnt := minKeys
 				if keyRange > 0 {
 					keyCnt += rand.Intn(keyRange)
 				}
+				if keyCnt > len(mykeys) {
+					keyCnt = len(mykeys)
+				}
 				shuffleKeys(mykeys)
+				op := workload.pick()
+				rec.IncInFlight(op.String())
 				start := time.Now()
-				cmd := client.MGet(mykeys[:keyCnt]...)
-				dur := time.Since(start)
-				if _, rerr := cmd.Result(); rerr != nil {
-					panic(rerr)
+				if ferr := runOp(client, fetcher, op, mykeys[:keyCnt], mykeys[0], sizeD); ferr != nil {
+					panic(ferr)
 				}
-				outchan <- scatterItem{keyCnt, dur}
+				dur := time.Since(start)
+				rec.DecInFlight(op.String())
+				rec.Observe(op.String(), dur)
+				outchan <- scatterItem{keyCnt, gx, op, dur}
 			}
-		}()
+		}(gx)
 	}
 	for c := 0; c < ctx.GlobalInt("cycles"); c++ {
 		indices <- c
@@ -270,7 +444,13 @@ func scatterAction(ctx *cli.Context) error {
 	wgWriter.Wait()
 
 	if ctx.Bool("gnuplot") {
-		fmt.Println("EOD")
+		for _, op := range activeOps {
+			fmt.Printf("$DATABLOCK_%s << EOD\n", strings.ToUpper(op.String()))
+			for _, si := range byOp[op] {
+				fmt.Printf("%d\t%0.3f\n", si.keys, float64(si.duration.Nanoseconds())/1000000.0)
+			}
+			fmt.Println("EOD")
+		}
 		fmt.Println(`set fit nolog`)
 		fmt.Println(`set fit quiet`)
 		fmt.Println(`set term pngcairo size 1280, 1024 font "sans,16"`)
@@ -279,81 +459,17 @@ func scatterAction(ctx *cli.Context) error {
 		for _, line := range ctx.StringSlice("gnuplot-extra") {
 			fmt.Println(line)
 		}
-		fmt.Println(`f(x) = a*x+b`)
-		fmt.Println(`fit f(x) $DATABLOCK via a,b`)
-		fmt.Printf("plot $DATABLOCK title \"mget (c=%d)\", f(x) with lines lw 3 title sprintf(\"y = %%0.6fx + %%0.6f\", a, b)\n", ctx.Int("concurrency"))
+		var plots []string
+		for i, op := range activeOps {
+			block := fmt.Sprintf("$DATABLOCK_%s", strings.ToUpper(op.String()))
+			f, a, b := fmt.Sprintf("f%d", i), fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)
+			fmt.Printf("%s(x) = %s*x+%s\n", f, a, b)
+			fmt.Printf("fit %s(x) %s via %s,%s\n", f, block, a, b)
+			plots = append(plots, fmt.Sprintf(`%s title "%s (c=%d)"`, block, op, ctx.Int("concurrency")))
+			plots = append(plots, fmt.Sprintf(`%s(x) with lines lw 3 title sprintf("%s: y = %%0.6fx + %%0.6f", %s, %s)`, f, op, a, b))
+		}
+		fmt.Printf("plot %s\n", strings.Join(plots, ", "))
 	}
 	_, err = clearTestKeys(client)
 	return err
 }
-
-func ctxToRedisOptions(ctx *cli.Context) *redis.Options {
-	return &redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", ctx.GlobalString("host"), ctx.GlobalInt("port")),
-		Password: ctx.GlobalString("password"),
-		DB:       ctx.GlobalInt("db"),
-	}
-}
-
-func getTestKeys(client *redis.Client, valueSize int) ([]string, error) {
-	var keys []string
-
-	iter := client.Scan(0, "test_*", 5000).Iterator()
-	for iter.Next() {
-		keys = append(keys, iter.Val())
-	}
-	buf := make([]byte, valueSize)
-	for len(keys) < 50000 {
-		key := fmt.Sprintf("test_%05d", len(keys))
-		if _, err := rand.Read(buf); err != nil {
-			return nil, err
-		}
-		if _, err := client.Set(key, buf, time.Duration(0)).Result(); err != nil {
-			return nil, err
-		}
-		keys = append(keys, key)
-	}
-	return keys, nil
-}
-
-func shuffleKeys(keys []string) {
-	rand.Shuffle(len(keys), func(i, j int) {
-		keys[i], keys[j] = keys[j], keys[i]
-	})
-}
-
-func clearTestKeys(client *redis.Client) (int64, error) {
-	total := int64(0)
-
-	kcnt := -1
-	for kcnt != 0 {
-		var keys []string
-		iter := client.Scan(0, "test_*", 5000).Iterator()
-		for iter.Next() {
-			keys = append(keys, iter.Val())
-		}
-		kcnt = len(keys)
-		if kcnt > 0 {
-			cnt, err := client.Del(keys...).Result()
-			total += cnt
-			if err != nil {
-				return total, err
-			}
-		}
-	}
-	return total, nil
-}
-
-func sortInt64(arr []int64) {
-	sort.Slice(arr, func(i, j int) bool {
-		return arr[i] < arr[j]
-	})
-}
-
-func medianInt64(data []int64) float64 {
-	l := len(data)
-	if l%2 == 0 {
-		return float64(data[l/2-1]+data[l/2+1]) / 2.0
-	}
-	return float64(data[l/2])
-}
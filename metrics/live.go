@@ -0,0 +1,31 @@
+package metrics
+
+import "time"
+
+// Live is the Recorder used when --metrics-addr is set: it fans every
+// observation out to both a Prometheus exporter and a terminal progress
+// line, so a long run can be scraped/graphed live and also watched in the
+// terminal without the old all-or-nothing end-of-cell report.
+type Live struct {
+	prom     *PrometheusRecorder
+	progress *Progress
+}
+
+// NewLive builds a Live recorder and starts its metrics HTTP server on
+// addr. totalOps seeds the progress line's ETA estimate; pass 0 if the
+// total operation count isn't known up front.
+func NewLive(addr string, totalOps int64) *Live {
+	prom := newPrometheusRecorder()
+	prom.Serve(addr)
+	return &Live{prom: prom, progress: NewProgress(totalOps)}
+}
+
+func (l *Live) IncInFlight(op string) { l.prom.IncInFlight(op) }
+func (l *Live) DecInFlight(op string) { l.prom.DecInFlight(op) }
+
+func (l *Live) Observe(op string, d time.Duration) {
+	l.prom.Observe(op, d)
+	l.progress.Observe(d)
+}
+
+func (l *Live) Finish() { l.progress.Done() }
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+)
+
+const (
+	progressHistMin     = int64(time.Microsecond)
+	progressHistMax     = int64(60 * time.Second)
+	progressHistSigFigs = 3
+
+	// progressRedrawInterval caps how often the line is actually redrawn.
+	// Every completed op still lands in the histogram, but at millions of
+	// cycles a synchronous stderr write per op would serialize every
+	// worker behind this one lock and dominate wall-clock time, so only a
+	// redraw due this often actually writes.
+	progressRedrawInterval = 100 * time.Millisecond
+)
+
+// Progress prints a single, repeatedly-overwritten terminal line showing
+// throughput, current p99 latency, and an ETA, so a long run gives live
+// feedback instead of going silent until a whole (key-count, concurrency)
+// cell finishes.
+type Progress struct {
+	mu        sync.Mutex
+	total     int64
+	done      int64
+	start     time.Time
+	hist      *hdrhistogram.Histogram
+	lastPrint time.Time
+}
+
+// NewProgress creates a Progress line that estimates ETA against total
+// expected operations. Pass 0 if the total is unknown; the ETA is then
+// omitted.
+func NewProgress(total int64) *Progress {
+	return &Progress{
+		total: total,
+		start: time.Now(),
+		hist:  hdrhistogram.New(progressHistMin, progressHistMax, progressHistSigFigs),
+	}
+}
+
+// Observe records one completed operation's latency, accumulating it into
+// the histogram every time, and reprints the line if it's due for a
+// redraw.
+func (p *Progress) Observe(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	_ = p.hist.RecordValue(d.Nanoseconds())
+	if now := time.Now(); now.Sub(p.lastPrint) >= progressRedrawInterval {
+		p.lastPrint = now
+		p.print()
+	}
+}
+
+func (p *Progress) print() {
+	elapsed := time.Since(p.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed
+	}
+	p99 := float64(p.hist.ValueAtQuantile(99)) / 1e6
+
+	eta := "?"
+	if rate > 0 && p.total > p.done {
+		remaining := time.Duration(float64(p.total-p.done) / rate * float64(time.Second))
+		eta = remaining.Truncate(time.Second).String()
+	}
+	fmt.Fprintf(os.Stderr, "\r%d/%d ops  %.1f ops/s  p99=%.3fms  eta=%s   ", p.done, p.total, rate, p99, eta)
+}
+
+// Done clears the progress line once the run finishes.
+func (p *Progress) Done() {
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
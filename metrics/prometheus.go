@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// PrometheusRecorder exposes per-op counters, in-flight gauges, and a
+// latency histogram in Prometheus text format. It registers against its
+// own registry rather than prometheus.DefaultRegisterer, so nothing
+// collides if a process ever builds more than one.
+type PrometheusRecorder struct {
+	registry *prometheus.Registry
+	opsTotal *prometheus.CounterVec
+	inFlight *prometheus.GaugeVec
+	latency  *prometheus.HistogramVec
+}
+
+func newPrometheusRecorder() *PrometheusRecorder {
+	r := &PrometheusRecorder{registry: prometheus.NewRegistry()}
+	r.opsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goredisperf_ops_total",
+		Help: "Total number of operations completed, by op type.",
+	}, []string{"op"})
+	r.inFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "goredisperf_in_flight",
+		Help: "Operations currently in flight, by op type.",
+	}, []string{"op"})
+	r.latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "goredisperf_latency_seconds",
+		Help:    "Observed operation latency in seconds, by op type.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 20), // 100us .. ~52s
+	}, []string{"op"})
+	r.registry.MustRegister(r.opsTotal, r.inFlight, r.latency)
+	return r
+}
+
+func (r *PrometheusRecorder) IncInFlight(op string) { r.inFlight.WithLabelValues(op).Inc() }
+func (r *PrometheusRecorder) DecInFlight(op string) { r.inFlight.WithLabelValues(op).Dec() }
+
+func (r *PrometheusRecorder) Observe(op string, d time.Duration) {
+	r.opsTotal.WithLabelValues(op).Inc()
+	r.latency.WithLabelValues(op).Observe(d.Seconds())
+}
+
+// Serve starts an HTTP server exposing r's metrics at /metrics on addr, in
+// a background goroutine. A bind failure is logged rather than returned,
+// since the benchmark itself should keep running even if the exporter
+// can't start.
+func (r *PrometheusRecorder) Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "metrics server on %s: %v\n", addr, err)
+		}
+	}()
+}
@@ -0,0 +1,29 @@
+// Package metrics exposes live observability for a running benchmark: a
+// Prometheus/OpenMetrics exporter and a terminal progress line, behind a
+// single Recorder interface so concurrencyAction and scatterAction can feed
+// it identically instead of each wiring up their own reporting.
+package metrics
+
+import "time"
+
+// Recorder receives per-operation bookkeeping from a benchmark's worker
+// goroutines: in-flight counts and completed-operation latencies, keyed by
+// op name ("read", "write", "del", ...). Implementations may fan this out
+// to a Prometheus registry, a terminal progress line, both, or neither.
+type Recorder interface {
+	IncInFlight(op string)
+	DecInFlight(op string)
+	Observe(op string, d time.Duration)
+	// Finish is called once after a run completes, to flush or clean up
+	// any live display the Recorder owns.
+	Finish()
+}
+
+// NopRecorder discards everything. It is the Recorder used when
+// --metrics-addr is left unset, so the hot path pays no bookkeeping cost.
+type NopRecorder struct{}
+
+func (NopRecorder) IncInFlight(op string)              {}
+func (NopRecorder) DecInFlight(op string)              {}
+func (NopRecorder) Observe(op string, d time.Duration) {}
+func (NopRecorder) Finish()                            {}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/go-redis/redis"
+	"github.com/urfave/cli"
+)
+
+// ctxToRedisOptions builds the go-redis UniversalOptions for the given CLI
+// context. Depending on which flags are present it targets a single node, a
+// Sentinel-monitored failover master, or a Redis Cluster; all three modes
+// are exposed to callers through the same redis.UniversalClient /
+// redis.Cmdable interfaces, so the benchmarking code never needs to care
+// which one is in play.
+func ctxToRedisOptions(ctx *cli.Context) *redis.UniversalOptions {
+	opts := &redis.UniversalOptions{
+		Password: ctx.GlobalString("password"),
+		DB:       ctx.GlobalInt("db"),
+	}
+
+	hostAddr := fmt.Sprintf("%s:%d", ctx.GlobalString("host"), ctx.GlobalInt("port"))
+
+	switch {
+	case ctx.GlobalString("sentinel-master") != "":
+		opts.MasterName = ctx.GlobalString("sentinel-master")
+		opts.Addrs = ctx.GlobalStringSlice("sentinel-addr")
+		if len(opts.Addrs) == 0 {
+			opts.Addrs = []string{hostAddr}
+		}
+	case len(ctx.GlobalStringSlice("cluster-addr")) > 0:
+		opts.Addrs = append([]string{hostAddr}, ctx.GlobalStringSlice("cluster-addr")...)
+	default:
+		opts.Addrs = []string{hostAddr}
+	}
+
+	return opts
+}
+
+// newRedisClient builds the redis.UniversalClient for the given CLI
+// context: a *redis.ClusterClient when --cluster-addr seeds are given, a
+// Sentinel-backed failover *redis.Client when --sentinel-master is set, or
+// a plain *redis.Client otherwise.
+func newRedisClient(ctx *cli.Context) redis.UniversalClient {
+	return redis.NewUniversalClient(ctxToRedisOptions(ctx))
+}
+
+// isClusterScatterMode reports whether ctx targets a Redis Cluster
+// (--cluster-addr set) with --cluster-hashtag-slots left at its zero
+// default, the mode that intentionally scatters keys across every shard.
+// go-redis's *redis.ClusterClient routes a multi-key command to a single
+// node based on the first key's slot rather than fanning a cross-slot
+// command out per-node, so Redis itself rejects a cross-slot MGET with a
+// CROSSSLOT error on almost every cycle in this mode.
+func isClusterScatterMode(ctx *cli.Context) bool {
+	return len(ctx.GlobalStringSlice("cluster-addr")) > 0 && ctx.GlobalInt("cluster-hashtag-slots") <= 0
+}
+
+// crossSlotUnsafeStrategies lists the --strategy values that issue a
+// single multi-key command (MGET, EVALSHA) rather than a pipeline of
+// single-key commands, so go-redis routes them to one node off the first
+// key and a real Redis Cluster rejects them with CROSSSLOT once the keys
+// span more than one slot.
+var crossSlotUnsafeStrategies = map[string]bool{"mget": true, "lua": true}
+
+// validateClusterScatterStrategy rejects a crossSlotUnsafeStrategies
+// --strategy when it's paired with cross-slot cluster scatter (see
+// isClusterScatterMode), and rejects a --cluster-hashtag-slots value that
+// would leave some group empty. Use --strategy=pipeline or
+// --strategy=txpipeline, which go-redis does split per-node, or set
+// --cluster-hashtag-slots>0 to pin every worker's keys to one shard
+// instead.
+func validateClusterScatterStrategy(ctx *cli.Context) error {
+	if slots := ctx.GlobalInt("cluster-hashtag-slots"); slots > testKeyPoolSize {
+		return fmt.Errorf("cluster-hashtag-slots (%d) cannot exceed the test key pool size (%d); a larger value leaves some groups empty and a worker pinned to one would panic", slots, testKeyPoolSize)
+	}
+	strategy := ctx.GlobalString("strategy")
+	if !crossSlotUnsafeStrategies[strategy] || !isClusterScatterMode(ctx) {
+		return nil
+	}
+	return fmt.Errorf("--strategy=%s cannot exercise cross-slot cluster scatter (Redis Cluster rejects a cross-slot %s with CROSSSLOT); use --strategy=pipeline or --strategy=txpipeline, or set --cluster-hashtag-slots>0", strategy, strategy)
+}
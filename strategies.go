@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	hdrhistogram "github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/go-redis/redis"
+	"github.com/urfave/cli"
+)
+
+// strategiesAction benchmarks every fetch strategy (mget, pipeline,
+// txpipeline, lua) against the same keyset at each key count, so the
+// relative cost of each approach is visible at a glance instead of
+// requiring separate runs with --strategy.
+func strategiesAction(ctx *cli.Context) error {
+	if ctx.Int("concurrency") < 1 {
+		return errors.New("concurrency must be greater than 0")
+	}
+	if ctx.GlobalInt("cycles") < 1 {
+		return errors.New("cycles must be greater than 0")
+	}
+	if isClusterScatterMode(ctx) {
+		return errors.New("strategies compares mget and lua alongside the others, and neither can exercise cross-slot cluster scatter (Redis Cluster rejects their cross-slot multi-key commands with CROSSSLOT); set --cluster-hashtag-slots>0 to pin keys to one shard")
+	}
+	if err := validateClusterScatterStrategy(ctx); err != nil {
+		return err
+	}
+
+	client := newRedisClient(ctx)
+	defer client.Close() //nolint
+
+	if _, err := clearTestKeys(client); err != nil {
+		panic(err)
+	}
+
+	sizeD, err := resolveSizeDist(ctx)
+	if err != nil {
+		return err
+	}
+
+	hashtagSlots := ctx.GlobalInt("cluster-hashtag-slots")
+	keys, err := getTestKeys(client, sizeD, hashtagSlots)
+	if err != nil {
+		panic(err)
+	}
+	groups := groupKeysByShard(keys, hashtagSlots)
+
+	script, err := loadFetchScript(client)
+	if err != nil {
+		panic(err)
+	}
+	fetchers := make([]Fetcher, 0, len(fetcherNames))
+	for _, name := range fetcherNames {
+		f, ferr := newFetcher(name, script)
+		if ferr != nil {
+			panic(ferr)
+		}
+		fetchers = append(fetchers, f)
+	}
+
+	counts := []int{1, 1}
+	for i := 5; i <= 100; i += 5 {
+		counts = append(counts, i)
+	}
+	conc := ctx.Int("concurrency")
+	cycles := ctx.GlobalInt("cycles")
+
+	fmt.Print("keys")
+	for _, f := range fetchers {
+		fmt.Printf("\t%s-p50\t%s-p99\t%s-ops/s", f.Name(), f.Name(), f.Name())
+	}
+	fmt.Println()
+
+	var dropped int64
+	for _, cnt := range counts {
+		fmt.Print(cnt)
+		for _, f := range fetchers {
+			hist, drop, wall := runFetcherCycles(client, groups, cnt, conc, cycles, f)
+			dropped += drop
+			throughput := float64(cycles) / wall.Seconds()
+			p := percentilesFromHistogram(hist)
+			fmt.Printf("\t%0.3f\t%0.3f\t%0.1f", p.p50, p.p99, throughput)
+		}
+		fmt.Println()
+	}
+
+	if dropped > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d samples fell outside [%s, %s] and were dropped from the histograms\n", dropped, time.Duration(histMinValue), time.Duration(histMaxValue))
+	}
+
+	cnt, err := clearTestKeys(client)
+	fmt.Printf("Deleted %d test keys\n", cnt)
+	return err
+}
+
+// runFetcherCycles runs `cycles` fetches of `cnt` keys spread across `conc`
+// workers using fetcher, drawing keys round-robin from groups (a single
+// group covering every key unless cluster hashtag grouping is in use). It
+// returns the merged per-fetch latency histogram, the number of samples
+// that fell outside the histogram's tracked range, and the wall-clock time
+// for the whole run.
+func runFetcherCycles(client redis.UniversalClient, groups [][]string, cnt, conc, cycles int, fetcher Fetcher) (*hdrhistogram.Histogram, int64, time.Duration) {
+	hists := make([]*hdrhistogram.Histogram, conc)
+	dropped := make([]int64, conc)
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for t := 0; t < conc; t++ {
+		wg.Add(1)
+		gx := t % len(groups)
+		hist := newLatencyHistogram()
+		hists[t] = hist
+		go func(gx, t int, hist *hdrhistogram.Histogram) {
+			client.Get("fake") // warm up client
+			defer wg.Done()
+			mykeys := make([]string, len(groups[gx])) // copy for safety
+			copy(mykeys, groups[gx])
+			n := cnt
+			if n > len(mykeys) {
+				n = len(mykeys)
+			}
+			for range indices {
+				shuffleKeys(mykeys)
+				fstart := time.Now()
+				if err := fetcher.Fetch(client, mykeys[:n]); err != nil {
+					panic(err)
+				}
+				if err := hist.RecordValue(time.Since(fstart).Nanoseconds()); err != nil {
+					dropped[t]++
+				}
+			}
+		}(gx, t, hist)
+	}
+	for c := 0; c < cycles; c++ {
+		indices <- c
+	}
+	close(indices)
+	wg.Wait()
+
+	var totalDropped int64
+	for _, d := range dropped {
+		totalDropped += d
+	}
+	return mergeHistograms(hists...), totalDropped, time.Since(start)
+}
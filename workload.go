@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis"
+	"github.com/urfave/cli"
+)
+
+// opType is the kind of operation a worker performs on a given iteration.
+type opType int
+
+const (
+	opRead opType = iota
+	opWrite
+	opDel
+)
+
+func (o opType) String() string {
+	switch o {
+	case opWrite:
+		return "write"
+	case opDel:
+		return "del"
+	default:
+		return "read"
+	}
+}
+
+// Workload picks an op type per iteration according to configured ratios,
+// so a single worker loop can benchmark a realistic read/write/delete mix
+// instead of only bulk reads.
+type Workload struct {
+	readRatio, writeRatio, delRatio float64
+}
+
+// newWorkload normalizes read/write/del ratios to sum to 1. At least one
+// ratio must be positive.
+func newWorkload(read, write, del float64) (*Workload, error) {
+	if read < 0 || write < 0 || del < 0 {
+		return nil, fmt.Errorf("read-ratio, write-ratio, and del-ratio must not be negative")
+	}
+	total := read + write + del
+	if total <= 0 {
+		return nil, fmt.Errorf("at least one of read-ratio, write-ratio, or del-ratio must be positive")
+	}
+	return &Workload{readRatio: read / total, writeRatio: write / total, delRatio: del / total}, nil
+}
+
+// pick draws an op type weighted by the workload's ratios.
+func (w *Workload) pick() opType {
+	r := rand.Float64()
+	if r < w.readRatio {
+		return opRead
+	}
+	if r < w.readRatio+w.writeRatio {
+		return opWrite
+	}
+	return opDel
+}
+
+// activeOps lists the op types with a positive ratio, in read/write/del
+// order, for use as report columns and gnuplot series.
+func (w *Workload) activeOps() []opType {
+	var ops []opType
+	if w.readRatio > 0 {
+		ops = append(ops, opRead)
+	}
+	if w.writeRatio > 0 {
+		ops = append(ops, opWrite)
+	}
+	if w.delRatio > 0 {
+		ops = append(ops, opDel)
+	}
+	return ops
+}
+
+// sizeDist samples a value size in bytes, in whatever distribution it
+// models.
+type sizeDist interface {
+	sample() int
+}
+
+type fixedSizeDist struct{ size int }
+
+func (d fixedSizeDist) sample() int { return d.size }
+
+type uniformSizeDist struct{ min, max int }
+
+func (d uniformSizeDist) sample() int { return d.min + rand.Intn(d.max-d.min+1) }
+
+// zipfSizeDist skews towards its minimum size, modeling a cache where most
+// values are small but a long tail runs much larger. rand.Zipf is not
+// safe for concurrent use, so access is serialized with a mutex.
+type zipfSizeDist struct {
+	min int
+	mu  sync.Mutex
+	z   *rand.Zipf
+}
+
+func (d *zipfSizeDist) sample() int {
+	d.mu.Lock()
+	v := d.z.Uint64()
+	d.mu.Unlock()
+	return d.min + int(v)
+}
+
+// parseSizeDist parses a --size-dist value: "fixed:N", "uniform:min:max",
+// or "zipf:min:max:s" (s is the Zipf exponent, greater than 1; values
+// closer to 1 produce a heavier tail).
+func parseSizeDist(spec string) (sizeDist, error) {
+	parts := strings.Split(spec, ":")
+	switch parts[0] {
+	case "fixed":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("size-dist %q: want fixed:N", spec)
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("size-dist %q: size must be a positive integer", spec)
+		}
+		return fixedSizeDist{size: n}, nil
+	case "uniform":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("size-dist %q: want uniform:min:max", spec)
+		}
+		lo, err1 := strconv.Atoi(parts[1])
+		hi, err2 := strconv.Atoi(parts[2])
+		if err1 != nil || err2 != nil || lo <= 0 || lo > hi {
+			return nil, fmt.Errorf("size-dist %q: want 0 < min <= max", spec)
+		}
+		return uniformSizeDist{min: lo, max: hi}, nil
+	case "zipf":
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("size-dist %q: want zipf:min:max:s", spec)
+		}
+		lo, err1 := strconv.Atoi(parts[1])
+		hi, err2 := strconv.Atoi(parts[2])
+		s, err3 := strconv.ParseFloat(parts[3], 64)
+		if err1 != nil || err2 != nil || err3 != nil || lo <= 0 || lo > hi || s <= 1 {
+			return nil, fmt.Errorf("size-dist %q: want 0 < min <= max and s > 1", spec)
+		}
+		z := rand.NewZipf(rand.New(rand.NewSource(1)), s, 1, uint64(hi-lo))
+		if z == nil {
+			return nil, fmt.Errorf("size-dist %q: invalid zipf parameters", spec)
+		}
+		return &zipfSizeDist{min: lo, z: z}, nil
+	default:
+		return nil, fmt.Errorf("unknown size-dist %q (want fixed, uniform, or zipf)", parts[0])
+	}
+}
+
+// resolveSizeDist builds the sizeDist for the run: --size-dist if given,
+// otherwise a fixed distribution at --data-size, which preserves the
+// original fixed-value-size behaviour when the new flag is left unset.
+func resolveSizeDist(ctx *cli.Context) (sizeDist, error) {
+	if spec := ctx.GlobalString("size-dist"); spec != "" {
+		return parseSizeDist(spec)
+	}
+	return fixedSizeDist{size: ctx.GlobalInt("data-size")}, nil
+}
+
+// resolveWorkload builds the Workload for the run from the ratio flags.
+// Leaving all three unset preserves the original all-read behaviour.
+func resolveWorkload(ctx *cli.Context) (*Workload, error) {
+	return newWorkload(ctx.GlobalFloat64("read-ratio"), ctx.GlobalFloat64("write-ratio"), ctx.GlobalFloat64("del-ratio"))
+}
+
+// runOp executes one operation of the given type against client: a read
+// fetches readKeys via fetcher, a write sets writeKey to a freshly sampled
+// value, and a del removes writeKey and immediately recreates it so the
+// keyspace size stays stable across a long run.
+func runOp(client redis.Cmdable, fetcher Fetcher, op opType, readKeys []string, writeKey string, sizeD sizeDist) error {
+	switch op {
+	case opWrite:
+		return setRandomValue(client, writeKey, sizeD)
+	case opDel:
+		if err := client.Del(writeKey).Err(); err != nil {
+			return err
+		}
+		return setRandomValue(client, writeKey, sizeD)
+	default:
+		return fetcher.Fetch(client, readKeys)
+	}
+}
+
+func setRandomValue(client redis.Cmdable, key string, sizeD sizeDist) error {
+	buf := make([]byte, sizeD.sample())
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	return client.Set(key, buf, 0).Err()
+}
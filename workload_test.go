@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestParseSizeDist(t *testing.T) {
+	if d, err := parseSizeDist("fixed:100"); err != nil || d.sample() != 100 {
+		t.Errorf("parseSizeDist(fixed:100) = %v, %v, want a fixed 100-byte distribution", d, err)
+	}
+	if _, err := parseSizeDist("fixed:0"); err == nil {
+		t.Error("parseSizeDist(fixed:0) should reject a non-positive size")
+	}
+
+	d, err := parseSizeDist("uniform:10:20")
+	if err != nil {
+		t.Fatalf("parseSizeDist(uniform:10:20): %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if n := d.sample(); n < 10 || n > 20 {
+			t.Fatalf("uniform:10:20 sampled %d, want [10, 20]", n)
+		}
+	}
+	if _, err := parseSizeDist("uniform:20:10"); err == nil {
+		t.Error("parseSizeDist(uniform:20:10) should reject min > max")
+	}
+
+	if _, err := parseSizeDist("zipf:10:20:1.5"); err != nil {
+		t.Errorf("parseSizeDist(zipf:10:20:1.5): %v", err)
+	}
+	if _, err := parseSizeDist("zipf:10:20:0.5"); err == nil {
+		t.Error("parseSizeDist(zipf:10:20:0.5) should reject s <= 1")
+	}
+
+	if _, err := parseSizeDist("bogus:1"); err == nil {
+		t.Error("parseSizeDist(bogus:1) should reject an unknown kind")
+	}
+}
+
+func TestNewWorkload(t *testing.T) {
+	if _, err := newWorkload(0, 0, 0); err == nil {
+		t.Error("newWorkload(0, 0, 0) should reject an all-zero mix")
+	}
+	if _, err := newWorkload(-1, 0, 0); err == nil {
+		t.Error("newWorkload(-1, 0, 0) should reject a negative ratio")
+	}
+
+	w, err := newWorkload(1, 1, 0)
+	if err != nil {
+		t.Fatalf("newWorkload(1, 1, 0): %v", err)
+	}
+	ops := w.activeOps()
+	if len(ops) != 2 || ops[0] != opRead || ops[1] != opWrite {
+		t.Errorf("activeOps() = %v, want [read write]", ops)
+	}
+
+	readOnly, err := newWorkload(1, 0, 0)
+	if err != nil {
+		t.Fatalf("newWorkload(1, 0, 0): %v", err)
+	}
+	if ops := readOnly.activeOps(); len(ops) != 1 || ops[0] != opRead {
+		t.Errorf("activeOps() = %v, want [read]", ops)
+	}
+	for i := 0; i < 100; i++ {
+		if op := readOnly.pick(); op != opRead {
+			t.Fatalf("read-only workload picked %v, want read", op)
+		}
+	}
+}